@@ -0,0 +1,322 @@
+package tritonhttp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// errFastCGIInvalidPath is returned by handleFastCGI when req.URL
+// contains a ".." segment, so handleGoodRequest can answer 404 instead of
+// its usual 502 Bad Gateway for upstream failures.
+var errFastCGIInvalidPath = errors.New("fastcgi: URL contains \"..\"")
+
+// FastCGIRoute configures a virtual host rule that forwards requests whose
+// URL starts with Prefix to a FastCGI application server instead of
+// serving them from the docroot.
+type FastCGIRoute struct {
+	// Prefix is the URL prefix this rule applies to, e.g. "/app/".
+	Prefix string
+
+	// Network is the dial network for the FastCGI server, e.g. "tcp"
+	// or "unix".
+	Network string
+
+	// Addr is the dial address, e.g. "127.0.0.1:9000" or
+	// "/run/php-fpm.sock".
+	Addr string
+}
+
+// FastCGI record types and roles, as defined by the FastCGI spec.
+const (
+	fcgiVersion1 = 1
+
+	fcgiBeginRequest = 1
+	fcgiEndRequest   = 3
+	fcgiParams       = 4
+	fcgiStdin        = 5
+	fcgiStdout       = 6
+	fcgiStderr       = 7
+
+	fcgiRoleResponder = 1
+
+	fcgiMaxWrite = 65535 // max content length of a single record
+)
+
+// fcgiHeader is the 8-byte record header that precedes every FastCGI
+// record on the wire.
+type fcgiHeader struct {
+	Version       uint8
+	Type          uint8
+	RequestID     uint16
+	ContentLength uint16
+	PaddingLength uint8
+	Reserved      uint8
+}
+
+// fcgiBeginRequestBody is the content of an FCGI_BEGIN_REQUEST record.
+type fcgiBeginRequestBody struct {
+	Role     uint16
+	Flags    uint8
+	Reserved [5]byte
+}
+
+// matchFastCGIRoute returns the longest-prefix FastCGIRoute configured for
+// host that matches url, if any.
+func (s *Server) matchFastCGIRoute(host, url string) (FastCGIRoute, bool) {
+	var best FastCGIRoute
+	found := false
+	for _, route := range s.FastCGIRoutes[host] {
+		if strings.HasPrefix(url, route.Prefix) && len(route.Prefix) > len(best.Prefix) {
+			best = route
+			found = true
+		}
+	}
+	return best, found
+}
+
+// handleFastCGI forwards req to the FastCGI application server described
+// by route and fills res in place from its CGI-style output. The caller
+// is expected to have Reset res beforehand.
+func (s *Server) handleFastCGI(req *Request, route FastCGIRoute, docroot string, res *Response) error {
+	const reqID = 1
+
+	scriptName := req.URL
+	queryString := ""
+	if i := strings.IndexByte(scriptName, '?'); i >= 0 {
+		queryString = scriptName[i+1:]
+		scriptName = scriptName[:i]
+	}
+
+	// FastCGI routes bypass the generic docroot filepath.Rel containment
+	// check, so SCRIPT_FILENAME/SCRIPT_NAME need their own guard against
+	// ".." segments: otherwise a URL like "/app/../../../etc/passwd"
+	// would join straight through docroot and hand the application
+	// server an arbitrary path on the host filesystem. Check this before
+	// dialing so a malicious URL never even reaches the upstream.
+	if strings.Contains(scriptName, "..") {
+		return errFastCGIInvalidPath
+	}
+
+	conn, err := net.Dial(route.Network, route.Addr)
+	if err != nil {
+		return fmt.Errorf("fastcgi: dial %s %s: %w", route.Network, route.Addr, err)
+	}
+	defer conn.Close()
+
+	params := map[string]string{
+		"SCRIPT_FILENAME":   filepath.Join(docroot, scriptName),
+		"SCRIPT_NAME":       scriptName,
+		"DOCUMENT_ROOT":     docroot,
+		"QUERY_STRING":      queryString,
+		"REQUEST_METHOD":    req.Method,
+		"SERVER_PROTOCOL":   req.Proto,
+		"GATEWAY_INTERFACE": "CGI/1.1",
+	}
+	req.Headers.Range(func(k, v string) {
+		switch k {
+		case "Content-Length", "Content-Type":
+			params[strings.ToUpper(strings.ReplaceAll(k, "-", "_"))] = v
+		default:
+			params["HTTP_"+strings.ToUpper(strings.ReplaceAll(k, "-", "_"))] = v
+		}
+	})
+
+	if err := writeFCGIBeginRequest(conn, reqID, fcgiRoleResponder, 0); err != nil {
+		return err
+	}
+	if err := writeFCGIParams(conn, reqID, params); err != nil {
+		return err
+	}
+	// GET requests have no body, so the FCGI_STDIN stream is just its
+	// empty terminating record.
+	if err := writeFCGIRecord(conn, fcgiStdin, reqID, nil); err != nil {
+		return err
+	}
+
+	stdout, err := readFCGIResponse(conn, reqID)
+	if err != nil {
+		return err
+	}
+
+	br := bufio.NewReader(bytes.NewReader(stdout))
+	statusCode, err := parseCGIHeaders(br, res)
+	if err != nil {
+		return err
+	}
+
+	res.init(req)
+	res.StatusCode = statusCode
+	res.StatusText = statusText[statusCode]
+	body, _ := io.ReadAll(br)
+	res.Headers.Set("Content-Length", fmt.Sprint(len(body)))
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	return nil
+}
+
+// parseCGIHeaders reads a block of CGI-style "Key: Value" header lines
+// terminated by a blank line into res.Headers, pulling out an optional
+// "Status" line as the response status code (defaulting to 200 OK when
+// absent).
+func parseCGIHeaders(br *bufio.Reader, res *Response) (statusCode int, err error) {
+	statusCode = statusOK
+
+	for {
+		line, err := readLine(br)
+		if err != nil {
+			return 0, fmt.Errorf("fastcgi: reading CGI headers: %w", err)
+		}
+		if line == "" {
+			break
+		}
+		if !strings.Contains(line, ":") {
+			return 0, fmt.Errorf("fastcgi: invalid CGI header line %q", line)
+		}
+		parts := strings.SplitN(line, ":", 2)
+		key := CanonicalHeaderKey(strings.TrimSpace(parts[0]))
+		val := strings.TrimSpace(parts[1])
+		if key == "Status" {
+			if _, err := fmt.Sscanf(val, "%d", &statusCode); err != nil {
+				return 0, fmt.Errorf("fastcgi: invalid Status header %q", val)
+			}
+			continue
+		}
+		res.Headers.Set(key, val)
+	}
+	return statusCode, nil
+}
+
+// writeFCGIBeginRequest writes an FCGI_BEGIN_REQUEST record starting a new
+// request with the given role and flags.
+func writeFCGIBeginRequest(w io.Writer, reqID uint16, role uint16, flags uint8) error {
+	buf := new(bytes.Buffer)
+	body := fcgiBeginRequestBody{Role: role, Flags: flags}
+	if err := binary.Write(buf, binary.BigEndian, body); err != nil {
+		return err
+	}
+	return writeFCGIRecord(w, fcgiBeginRequest, reqID, buf.Bytes())
+}
+
+// writeFCGIParams encodes params as a stream of FCGI_PARAMS records,
+// terminated by the required empty record.
+func writeFCGIParams(w io.Writer, reqID uint16, params map[string]string) error {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	buf := new(bytes.Buffer)
+	for _, k := range keys {
+		v := params[k]
+		writeFCGIParamLength(buf, len(k))
+		writeFCGIParamLength(buf, len(v))
+		buf.WriteString(k)
+		buf.WriteString(v)
+	}
+	if err := writeFCGIRecord(w, fcgiParams, reqID, buf.Bytes()); err != nil {
+		return err
+	}
+	return writeFCGIRecord(w, fcgiParams, reqID, nil)
+}
+
+// writeFCGIParamLength encodes a name/value length per the FastCGI spec:
+// one byte for lengths <= 127, four bytes (high bit set) otherwise.
+func writeFCGIParamLength(buf *bytes.Buffer, n int) {
+	if n <= 127 {
+		buf.WriteByte(byte(n))
+		return
+	}
+	buf.WriteByte(byte(n>>24) | 0x80)
+	buf.WriteByte(byte(n >> 16))
+	buf.WriteByte(byte(n >> 8))
+	buf.WriteByte(byte(n))
+}
+
+// writeFCGIRecord writes content as one or more records of the given type,
+// splitting it across multiple records if it exceeds fcgiMaxWrite and
+// padding each one out to an 8-byte boundary as required by the spec.
+func writeFCGIRecord(w io.Writer, recType uint8, reqID uint16, content []byte) error {
+	if len(content) == 0 {
+		return writeFCGIRecordChunk(w, recType, reqID, nil)
+	}
+	for len(content) > 0 {
+		n := len(content)
+		if n > fcgiMaxWrite {
+			n = fcgiMaxWrite
+		}
+		if err := writeFCGIRecordChunk(w, recType, reqID, content[:n]); err != nil {
+			return err
+		}
+		content = content[n:]
+	}
+	return nil
+}
+
+func writeFCGIRecordChunk(w io.Writer, recType uint8, reqID uint16, content []byte) error {
+	padding := (8 - len(content)%8) % 8
+	hdr := fcgiHeader{
+		Version:       fcgiVersion1,
+		Type:          recType,
+		RequestID:     reqID,
+		ContentLength: uint16(len(content)),
+		PaddingLength: uint8(padding),
+	}
+	if err := binary.Write(w, binary.BigEndian, hdr); err != nil {
+		return err
+	}
+	if len(content) > 0 {
+		if _, err := w.Write(content); err != nil {
+			return err
+		}
+	}
+	if padding > 0 {
+		if _, err := w.Write(make([]byte, padding)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readFCGIResponse reads records from r until the FCGI_END_REQUEST record
+// for reqID arrives, returning the concatenated FCGI_STDOUT payload.
+// FCGI_STDERR content is logged rather than surfaced to the client.
+func readFCGIResponse(r io.Reader, reqID uint16) ([]byte, error) {
+	var stdout bytes.Buffer
+	for {
+		var hdr fcgiHeader
+		if err := binary.Read(r, binary.BigEndian, &hdr); err != nil {
+			return nil, fmt.Errorf("fastcgi: reading record header: %w", err)
+		}
+		content := make([]byte, hdr.ContentLength)
+		if _, err := io.ReadFull(r, content); err != nil {
+			return nil, fmt.Errorf("fastcgi: reading record body: %w", err)
+		}
+		if hdr.PaddingLength > 0 {
+			if _, err := io.CopyN(io.Discard, r, int64(hdr.PaddingLength)); err != nil {
+				return nil, fmt.Errorf("fastcgi: discarding padding: %w", err)
+			}
+		}
+		if hdr.RequestID != reqID {
+			continue
+		}
+		switch hdr.Type {
+		case fcgiStdout:
+			stdout.Write(content)
+		case fcgiStderr:
+			if len(content) > 0 {
+				log.Printf("fastcgi stderr: %s", content)
+			}
+		case fcgiEndRequest:
+			return stdout.Bytes(), nil
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package tritonhttp
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"testing"
+)
+
+// serveFCGIOnce accepts a single connection on ln, reads (and discards)
+// the request records off it, then writes resp back as the sole
+// FCGI_STDOUT record followed by FCGI_END_REQUEST.
+func serveFCGIOnce(t *testing.T, ln net.Listener, resp []byte) {
+	t.Helper()
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Errorf("accept: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	// Drain FCGI_BEGIN_REQUEST, FCGI_PARAMS (x2), and FCGI_STDIN.
+	br := bufio.NewReader(conn)
+	for i := 0; i < 4; i++ {
+		var hdr fcgiHeader
+		if err := readFCGIHeader(br, &hdr); err != nil {
+			t.Errorf("reading record %d: %v", i, err)
+			return
+		}
+		if _, err := io.CopyN(io.Discard, br, int64(hdr.ContentLength)+int64(hdr.PaddingLength)); err != nil {
+			t.Errorf("discarding record %d body: %v", i, err)
+			return
+		}
+	}
+
+	if err := writeFCGIRecord(conn, fcgiStdout, 1, resp); err != nil {
+		t.Errorf("writing stdout: %v", err)
+		return
+	}
+	if err := writeFCGIRecord(conn, fcgiEndRequest, 1, make([]byte, 8)); err != nil {
+		t.Errorf("writing end request: %v", err)
+	}
+}
+
+func readFCGIHeader(r io.Reader, hdr *fcgiHeader) error {
+	buf := make([]byte, 8)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return err
+	}
+	hdr.Version = buf[0]
+	hdr.Type = buf[1]
+	hdr.RequestID = uint16(buf[2])<<8 | uint16(buf[3])
+	hdr.ContentLength = uint16(buf[4])<<8 | uint16(buf[5])
+	hdr.PaddingLength = buf[6]
+	return nil
+}
+
+func TestHandleFastCGI(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	go serveFCGIOnce(t, ln, []byte("Status: 200 OK\r\nContent-Type: text/plain\r\n\r\nhello"))
+
+	route := FastCGIRoute{Prefix: "/app/", Network: "tcp", Addr: ln.Addr().String()}
+	req := &Request{Method: "GET", URL: "/app/index.php", Proto: "HTTP/1.1", Host: "example.com"}
+	res := &Response{}
+
+	s := &Server{}
+	if err := s.handleFastCGI(req, route, "/var/www", res); err != nil {
+		t.Fatalf("handleFastCGI: %v", err)
+	}
+	if res.StatusCode != statusOK {
+		t.Errorf("StatusCode = %d, want %d", res.StatusCode, statusOK)
+	}
+	if got := res.Headers.Get("Content-Type"); got != "text/plain" {
+		t.Errorf("Content-Type = %q, want %q", got, "text/plain")
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("reading body: %v", err)
+	}
+	if string(body) != "hello" {
+		t.Errorf("body = %q, want %q", body, "hello")
+	}
+}
+
+func TestHandleFastCGIRejectsPathTraversal(t *testing.T) {
+	route := FastCGIRoute{Prefix: "/app/", Network: "tcp", Addr: "127.0.0.1:1"}
+	req := &Request{Method: "GET", URL: "/app/../../../etc/passwd", Proto: "HTTP/1.1", Host: "example.com"}
+	res := &Response{}
+
+	s := &Server{}
+	err := s.handleFastCGI(req, route, "/var/www", res)
+	if err == nil {
+		t.Fatal("handleFastCGI: expected an error for a URL containing \"..\", got nil")
+	}
+	if err != errFastCGIInvalidPath {
+		t.Errorf("handleFastCGI err = %v, want errFastCGIInvalidPath", err)
+	}
+}
+
+func TestMatchFastCGIRoute(t *testing.T) {
+	s := &Server{
+		FastCGIRoutes: map[string][]FastCGIRoute{
+			"example.com": {
+				{Prefix: "/app/", Network: "tcp", Addr: "127.0.0.1:9000"},
+				{Prefix: "/app/admin/", Network: "tcp", Addr: "127.0.0.1:9001"},
+			},
+		},
+	}
+
+	route, ok := s.matchFastCGIRoute("example.com", "/app/admin/login.php")
+	if !ok {
+		t.Fatal("matchFastCGIRoute: expected a match")
+	}
+	if route.Addr != "127.0.0.1:9001" {
+		t.Errorf("matched Addr = %q, want the longer /app/admin/ prefix's", route.Addr)
+	}
+
+	if _, ok := s.matchFastCGIRoute("example.com", "/static/logo.png"); ok {
+		t.Error("matchFastCGIRoute: expected no match for an unrelated prefix")
+	}
+}
@@ -0,0 +1,163 @@
+package tritonhttp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Handler responds to a single HTTP request by filling in res. It's the
+// extension point for everything handleGoodRequest doesn't special-case
+// itself (reverse proxying and FastCGI): static files, redirects, and any
+// user-defined logic composed through ServeMux and Chain.
+type Handler interface {
+	ServeTritonHTTP(res *Response, req *Request)
+}
+
+// HandlerFunc adapts an ordinary function to the Handler interface.
+type HandlerFunc func(res *Response, req *Request)
+
+// ServeTritonHTTP calls f.
+func (f HandlerFunc) ServeTritonHTTP(res *Response, req *Request) {
+	f(res, req)
+}
+
+// Middleware wraps a Handler to add cross-cutting behavior (logging,
+// gzip, basic auth, rate limiting, ...) without forking the server.
+type Middleware func(Handler) Handler
+
+// Chain wraps next with middlewares, applied in the order given: the
+// first middleware is outermost and runs first.
+func Chain(next Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		next = middlewares[i](next)
+	}
+	return next
+}
+
+// rootHandler returns the Handler requests are dispatched to once
+// reverse-proxy and FastCGI routing have been ruled out. If s.Handler is
+// unset, it lazily builds a ServeMux of FileServers out of
+// s.VirtualHosts, preserving TritonHTTP's historical per-vhost docroot
+// behavior.
+func (s *Server) rootHandler() Handler {
+	if s.Handler != nil {
+		return s.Handler
+	}
+	s.defaultHandlerOnce.Do(func() {
+		mux := NewServeMux()
+		for host, docroot := range s.VirtualHosts {
+			mux.Handle(host, "/", FileServer(docroot))
+		}
+		s.defaultHandler = mux
+	})
+	return s.defaultHandler
+}
+
+// ServeMux dispatches a request to the Handler registered for the
+// longest URL prefix matching it under its Host.
+type ServeMux struct {
+	mu     sync.RWMutex
+	routes map[string][]muxRoute
+}
+
+type muxRoute struct {
+	prefix  string
+	handler Handler
+}
+
+// NewServeMux returns an empty ServeMux.
+func NewServeMux() *ServeMux {
+	return &ServeMux{routes: make(map[string][]muxRoute)}
+}
+
+// Handle registers handler to serve requests for host whose URL starts
+// with prefix.
+func (m *ServeMux) Handle(host, prefix string, handler Handler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.routes[host] = append(m.routes[host], muxRoute{prefix: prefix, handler: handler})
+}
+
+// ServeTritonHTTP dispatches to the registered route with the longest
+// matching prefix, or answers 404 if none match.
+func (m *ServeMux) ServeTritonHTTP(res *Response, req *Request) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var best muxRoute
+	found := false
+	for _, route := range m.routes[req.Host] {
+		if strings.HasPrefix(req.URL, route.prefix) && len(route.prefix) > len(best.prefix) {
+			best = route
+			found = true
+		}
+	}
+	if !found {
+		res.handleNotFound(req)
+		return
+	}
+	best.handler.ServeTritonHTTP(res, req)
+}
+
+// FileServer returns a Handler that serves static files out of docroot,
+// the same way TritonHTTP has always served a vhost's docroot.
+func FileServer(docroot string) Handler {
+	return fileServer{docroot: docroot}
+}
+
+type fileServer struct {
+	docroot string
+}
+
+func (fs fileServer) ServeTritonHTTP(res *Response, req *Request) {
+	url := req.URL
+	if url == "" || url[len(url)-1] == '/' {
+		url += "index.html"
+	}
+	path := filepath.Join(fs.docroot, url)
+
+	pathRel, err := filepath.Rel(fs.docroot, path)
+	if err != nil || strings.HasPrefix(pathRel, "..") {
+		res.handleNotFound(req)
+		return
+	}
+	fi, err := os.Stat(path)
+	if err != nil || fi.IsDir() {
+		res.handleNotFound(req)
+		return
+	}
+
+	res.FilePath = path
+	res.handleOK(req)
+}
+
+// StripPrefix returns a Handler that removes prefix from the front of
+// req.URL before calling next, answering 404 for requests that don't
+// carry it.
+func StripPrefix(prefix string, next Handler) Handler {
+	return HandlerFunc(func(res *Response, req *Request) {
+		if !strings.HasPrefix(req.URL, prefix) {
+			res.handleNotFound(req)
+			return
+		}
+		trimmed := strings.TrimPrefix(req.URL, prefix)
+		if trimmed == "" || trimmed[0] != '/' {
+			trimmed = "/" + trimmed
+		}
+		req.URL = trimmed
+		next.ServeTritonHTTP(res, req)
+	})
+}
+
+// Redirect returns a Handler that answers every request with status
+// (e.g. 301 or 302) and a Location header of location.
+func Redirect(status int, location string) Handler {
+	return HandlerFunc(func(res *Response, req *Request) {
+		res.init(req)
+		res.StatusCode = status
+		res.StatusText = statusText[status]
+		res.Headers.Set("Location", location)
+	})
+}
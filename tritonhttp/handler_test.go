@@ -0,0 +1,123 @@
+package tritonhttp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type recordingHandler struct {
+	name   string
+	called *[]string
+}
+
+func (h recordingHandler) ServeTritonHTTP(res *Response, req *Request) {
+	*h.called = append(*h.called, h.name)
+}
+
+func TestServeMuxLongestPrefixMatch(t *testing.T) {
+	var called []string
+	mux := NewServeMux()
+	mux.Handle("example.com", "/", recordingHandler{"root", &called})
+	mux.Handle("example.com", "/app/", recordingHandler{"app", &called})
+	mux.Handle("example.com", "/app/admin/", recordingHandler{"admin", &called})
+	mux.Handle("other.com", "/", recordingHandler{"other-root", &called})
+
+	req := &Request{Host: "example.com", URL: "/app/admin/login"}
+	res := &Response{}
+	mux.ServeTritonHTTP(res, req)
+
+	if len(called) != 1 || called[0] != "admin" {
+		t.Errorf("called = %v, want [admin]", called)
+	}
+}
+
+func TestServeMuxNoMatchIsNotFound(t *testing.T) {
+	mux := NewServeMux()
+	mux.Handle("example.com", "/app/", recordingHandler{"app", &[]string{}})
+
+	req := &Request{Host: "example.com", URL: "/other"}
+	res := &Response{}
+	mux.ServeTritonHTTP(res, req)
+
+	if res.StatusCode != statusNotFound {
+		t.Errorf("StatusCode = %d, want %d", res.StatusCode, statusNotFound)
+	}
+}
+
+func TestChainOrdersMiddlewareOutermostFirst(t *testing.T) {
+	var order []string
+	mw := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return HandlerFunc(func(res *Response, req *Request) {
+				order = append(order, name+":before")
+				next.ServeTritonHTTP(res, req)
+				order = append(order, name+":after")
+			})
+		}
+	}
+	base := HandlerFunc(func(res *Response, req *Request) { order = append(order, "base") })
+
+	h := Chain(base, mw("outer"), mw("inner"))
+	h.ServeTritonHTTP(&Response{}, &Request{})
+
+	want := []string{"outer:before", "inner:before", "base", "inner:after", "outer:after"}
+	if len(order) != len(want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Errorf("order[%d] = %q, want %q (full: %v)", i, order[i], want[i], order)
+		}
+	}
+}
+
+func TestStripPrefix(t *testing.T) {
+	var gotURL string
+	next := HandlerFunc(func(res *Response, req *Request) { gotURL = req.URL })
+	h := StripPrefix("/api", next)
+
+	h.ServeTritonHTTP(&Response{}, &Request{URL: "/api/users"})
+	if gotURL != "/users" {
+		t.Errorf("stripped URL = %q, want %q", gotURL, "/users")
+	}
+
+	res := &Response{}
+	h.ServeTritonHTTP(res, &Request{URL: "/other"})
+	if res.StatusCode != statusNotFound {
+		t.Errorf("StatusCode for non-matching prefix = %d, want %d", res.StatusCode, statusNotFound)
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	res := &Response{}
+	h := Redirect(statusMovedPermanently, "https://example.com/new")
+	h.ServeTritonHTTP(res, &Request{})
+
+	if res.StatusCode != statusMovedPermanently {
+		t.Errorf("StatusCode = %d, want %d", res.StatusCode, statusMovedPermanently)
+	}
+	if got := res.Headers.Get("Location"); got != "https://example.com/new" {
+		t.Errorf("Location = %q, want %q", got, "https://example.com/new")
+	}
+}
+
+// TestFileServerTreatsFileAsDirAsNotFound is a regression test for a
+// panic: requesting a URL that walks through an existing file as if it
+// were a directory (e.g. "/existing.txt/extra") makes os.Stat fail with
+// something other than os.ErrNotExist (ENOTDIR), which used to fall
+// through to a nil-FileInfo.IsDir() dereference.
+func TestFileServerTreatsFileAsDirAsNotFound(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "existing.txt"), []byte("hi"), 0o644); err != nil {
+		t.Fatalf("writing fixture file: %v", err)
+	}
+
+	h := FileServer(dir)
+	res := &Response{}
+	h.ServeTritonHTTP(res, &Request{URL: "/existing.txt/extra"})
+
+	if res.StatusCode != statusNotFound {
+		t.Errorf("StatusCode = %d, want %d", res.StatusCode, statusNotFound)
+	}
+}
@@ -0,0 +1,97 @@
+package tritonhttp
+
+import "sort"
+
+// Headers is an ordered list of HTTP header key/value pairs, kept sorted
+// by key as pairs are Set. It backs Request.Headers and Response.Headers
+// instead of a map so pooled Requests and Responses can be reset between
+// uses by truncating a slice rather than discarding and reallocating map
+// buckets, and so writing them out doesn't need to sort on every call.
+type Headers struct {
+	pairs [][2]string
+}
+
+// Peek returns the value stored for key and whether it was present. key
+// is canonicalized before lookup.
+func (h *Headers) Peek(key string) (string, bool) {
+	key = CanonicalHeaderKey(key)
+	i := h.search(key)
+	if i < len(h.pairs) && h.pairs[i][0] == key {
+		return h.pairs[i][1], true
+	}
+	return "", false
+}
+
+// Get returns the value stored for key, or "" if it isn't present.
+func (h *Headers) Get(key string) string {
+	v, _ := h.Peek(key)
+	return v
+}
+
+// Set canonicalizes key and sets it to value, replacing any prior value.
+// Pairs are kept sorted by key so writing them out later doesn't need a
+// separate sort pass.
+func (h *Headers) Set(key, value string) {
+	key = CanonicalHeaderKey(key)
+	i := h.search(key)
+	if i < len(h.pairs) && h.pairs[i][0] == key {
+		h.pairs[i][1] = value
+		return
+	}
+	h.pairs = append(h.pairs, [2]string{})
+	copy(h.pairs[i+1:], h.pairs[i:])
+	h.pairs[i] = [2]string{key, value}
+}
+
+// Del removes key, if present.
+func (h *Headers) Del(key string) {
+	key = CanonicalHeaderKey(key)
+	i := h.search(key)
+	if i < len(h.pairs) && h.pairs[i][0] == key {
+		h.pairs = append(h.pairs[:i], h.pairs[i+1:]...)
+	}
+}
+
+// search returns the index of the first pair whose key is >= key.
+func (h *Headers) search(key string) int {
+	return sort.Search(len(h.pairs), func(i int) bool {
+		return h.pairs[i][0] >= key
+	})
+}
+
+// Len reports the number of header pairs stored.
+func (h *Headers) Len() int {
+	return len(h.pairs)
+}
+
+// Range calls fn for every header pair, in sorted key order.
+func (h *Headers) Range(fn func(key, value string)) {
+	for _, p := range h.pairs {
+		fn(p[0], p[1])
+	}
+}
+
+// SortedKeys returns the stored header keys. They're already kept in
+// sorted order, but this still allocates a fresh slice per call, so the
+// hot serialization path (Request.Write, Response.writeHeaders) iterates
+// pairs directly instead of calling it.
+func (h *Headers) SortedKeys() []string {
+	keys := make([]string, len(h.pairs))
+	for i, p := range h.pairs {
+		keys[i] = p[0]
+	}
+	return keys
+}
+
+// Reset empties h for reuse, retaining its backing array's capacity.
+func (h *Headers) Reset() {
+	h.pairs = h.pairs[:0]
+}
+
+// clone returns a deep copy of h with its own backing array, so a caller
+// can mutate the copy without affecting h.
+func (h *Headers) clone() Headers {
+	out := Headers{pairs: make([][2]string, len(h.pairs))}
+	copy(out.pairs, h.pairs)
+	return out
+}
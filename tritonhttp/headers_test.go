@@ -0,0 +1,119 @@
+package tritonhttp
+
+import (
+	"bufio"
+	"io"
+	"testing"
+)
+
+func TestHeadersSetKeepsPairsSorted(t *testing.T) {
+	var h Headers
+	h.Set("Host", "example.com")
+	h.Set("Accept", "*/*")
+	h.Set("Content-Type", "text/plain")
+	h.Set("Accept", "text/html") // overwrite, shouldn't duplicate or reorder
+
+	if h.Len() != 3 {
+		t.Fatalf("Len() = %d, want 3", h.Len())
+	}
+
+	var gotKeys []string
+	h.Range(func(k, v string) { gotKeys = append(gotKeys, k) })
+	want := []string{"Accept", "Content-Type", "Host"}
+	for i, k := range want {
+		if gotKeys[i] != k {
+			t.Errorf("pairs[%d] key = %q, want %q (order %v)", i, gotKeys[i], k, gotKeys)
+		}
+	}
+	if got := h.Get("Accept"); got != "text/html" {
+		t.Errorf("Get(Accept) = %q, want %q (overwrite)", got, "text/html")
+	}
+}
+
+func TestHeadersDel(t *testing.T) {
+	var h Headers
+	h.Set("Accept", "*/*")
+	h.Set("Host", "example.com")
+	h.Del("Accept")
+
+	if h.Len() != 1 {
+		t.Fatalf("Len() = %d after Del, want 1", h.Len())
+	}
+	if _, ok := h.Peek("Accept"); ok {
+		t.Error("Peek(Accept) found a value after Del")
+	}
+}
+
+// TestWriteStatusLineAndHeadersAllocFree checks the write side of the
+// zero-allocation hot path: writeStatusLine and writeHeaders, with
+// Headers already populated (the values themselves, produced upstream
+// by handleOK et al., aren't part of this hot path's allocation budget).
+// The read side (readRequest) is NOT allocation-free — see
+// TestReadRequestAllocates below, which measures it instead of
+// asserting zero.
+func TestWriteStatusLineAndHeadersAllocFree(t *testing.T) {
+	res := &Response{
+		Proto:      responseProto,
+		StatusCode: statusOK,
+	}
+	res.Headers.Set("Content-Type", "text/plain")
+	res.Headers.Set("Content-Length", "5")
+	res.Headers.Set("Date", "Thu, 01 Jan 1970 00:00:00 GMT")
+
+	bw := bufio.NewWriter(io.Discard)
+
+	allocs := testing.AllocsPerRun(1000, func() {
+		if err := res.writeStatusLine(bw); err != nil {
+			t.Fatal(err)
+		}
+		if err := res.writeHeaders(bw); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("writeStatusLine+writeHeaders allocated %.1f times per run, want 0", allocs)
+	}
+}
+
+// repeatingReader replays the same bytes forever, so a single persistent
+// bufio.Reader can be fed a warm loop of identical pipelined requests
+// without the reader-construction cost AllocsPerRun is trying to exclude.
+type repeatingReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *repeatingReader) Read(p []byte) (int, error) {
+	n := copy(p, r.data[r.pos:])
+	r.pos += n
+	if r.pos == len(r.data) {
+		r.pos = 0
+	}
+	return n, nil
+}
+
+// TestReadRequestAllocates measures readRequest's steady-state
+// allocations (a reused *bufio.Reader and pooled *Request, matching what
+// ServeConn actually does on a keep-alive connection) instead of
+// asserting zero: unlike the write path, readLine/parseRequestLine/the
+// header loop each still allocate a string or slice per call. This
+// documents the real number so a future change to the read path has a
+// baseline to compare against, per the request's original "warm-loop
+// pipelined GET" allocation claim — which this test shows doesn't hold
+// for the read side.
+func TestReadRequestAllocates(t *testing.T) {
+	raw := "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"
+	br := bufio.NewReader(&repeatingReader{data: []byte(raw)})
+	req := &Request{}
+
+	allocs := testing.AllocsPerRun(1000, func() {
+		req.Reset()
+		if _, err := readRequest(br, req); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Logf("readRequest allocates %.1f times per run (read path is not allocation-free)", allocs)
+	if allocs == 0 {
+		t.Error("readRequest allocated 0 times per run; if the read path has been made allocation-free, update this test's comment and request.go's readRequest doc comment to match")
+	}
+}
@@ -0,0 +1,52 @@
+package tritonhttp
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// upgradeHandler hijacks every connection and writes a 101 Switching
+// Protocols line directly, followed by a marker payload it owns from
+// then on.
+type upgradeHandler struct{}
+
+func (upgradeHandler) ServeTritonHTTP(res *Response, req *Request) {
+	conn, rw, err := res.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\n\r\nUPGRADED")
+	rw.Flush()
+}
+
+// TestServeConnDoesNotWriteAfterHijack is a regression test for a bug
+// where ServeConn wrote a second, stale status line onto the connection
+// right after a handler hijacked it and wrote its own bytes.
+func TestServeConnDoesNotWriteAfterHijack(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	s := &Server{Handler: upgradeHandler{}}
+	done := make(chan error, 1)
+	go func() { done <- s.ServeConn(server) }()
+
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := client.Write([]byte("GET /ws HTTP/1.1\r\nHost: example.com\r\n\r\n")); err != nil {
+		t.Fatalf("writing request: %v", err)
+	}
+
+	br := bufio.NewReader(client)
+	got, err := io.ReadAll(br)
+	if err != nil && err != io.EOF {
+		t.Fatalf("reading response: %v", err)
+	}
+
+	want := "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\n\r\nUPGRADED"
+	if string(got) != want {
+		t.Errorf("connection bytes = %q, want exactly %q (no trailing stale response)", got, want)
+	}
+}
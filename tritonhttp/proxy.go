@@ -0,0 +1,185 @@
+package tritonhttp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sync"
+)
+
+// ReverseProxy forwards every request for a virtual host to an upstream
+// HTTP server, instead of serving files from a docroot.
+type ReverseProxy struct {
+	// Network and Addr are the dial target for the upstream, e.g.
+	// "tcp" and "127.0.0.1:9000".
+	Network string
+	Addr    string
+
+	// Director, if set, is called on the outbound request (after hop-by-hop
+	// and X-Forwarded-* headers have been applied) so callers can rewrite
+	// its URL, Host, or headers before it is sent upstream.
+	Director func(*Request)
+
+	pool connPool
+}
+
+// NewReverseProxy builds a ReverseProxy dialing the host:port encoded in
+// upstream, e.g. "http://127.0.0.1:9000".
+func NewReverseProxy(upstream string) (*ReverseProxy, error) {
+	u, err := url.Parse(upstream)
+	if err != nil {
+		return nil, fmt.Errorf("reverse proxy: invalid upstream %q: %w", upstream, err)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "80")
+	}
+	return &ReverseProxy{Network: "tcp", Addr: addr}, nil
+}
+
+// hopByHopHeaders must not be forwarded to the upstream: they describe the
+// client-to-proxy hop, not the proxy-to-upstream one.
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"TE",
+	"Trailers",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// serve forwards req to p's upstream and fills res in place from the
+// response read back. The caller is expected to have Reset res beforehand.
+func (p *ReverseProxy) serve(req *Request, res *Response) error {
+	out := req.clone()
+
+	for _, h := range hopByHopHeaders {
+		out.Headers.Del(h)
+	}
+
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	if prior := out.Headers.Get("X-Forwarded-For"); prior != "" {
+		out.Headers.Set("X-Forwarded-For", prior+", "+host)
+	} else if host != "" {
+		out.Headers.Set("X-Forwarded-For", host)
+	}
+	out.Headers.Set("X-Forwarded-Host", out.Host)
+	out.Headers.Set("X-Forwarded-Proto", "http")
+
+	if p.Director != nil {
+		p.Director(out)
+	}
+
+	pc, err := p.pool.get(p.Network, p.Addr)
+	if err != nil {
+		return fmt.Errorf("dial %s %s: %w", p.Network, p.Addr, err)
+	}
+
+	if err := out.Write(pc.conn); err != nil {
+		pc.conn.Close()
+		return fmt.Errorf("writing request upstream: %w", err)
+	}
+
+	if err := ReadResponse(pc.br, res); err != nil {
+		pc.conn.Close()
+		return fmt.Errorf("reading upstream response: %w", err)
+	}
+
+	// A response can only be reused for a later request once its body
+	// has been fully read off the wire, and only if that body's extent
+	// is unambiguous (a Content-Length) and the upstream didn't ask to
+	// close the connection. Otherwise the next caller to read from this
+	// conn would either block on a connection the upstream already
+	// closed or start reading mid-body. The body isn't actually drained
+	// until writeBody copies it to the client, so defer the pool.put (or
+	// conn.Close) until res.Body is Closed rather than doing it here.
+	reusable := res.Headers.Get("Connection") != "close" && res.Headers.Get("Content-Length") != ""
+	res.Body = &pooledBody{
+		ReadCloser: res.Body,
+		pool:       &p.pool,
+		network:    p.Network,
+		addr:       p.Addr,
+		pc:         pc,
+		reusable:   reusable,
+	}
+	return nil
+}
+
+// pooledBody wraps an upstream response body so that closing it (which
+// writeBody does after copying the body to the client, or straight away
+// for a HEAD response, whose upstream reply carries no body) is what
+// returns the underlying connection to the pool, or closes it outright
+// if the response turned out not to be safely reusable. writeBody marks
+// a body whose copy failed as not reusable before closing it.
+type pooledBody struct {
+	io.ReadCloser
+	pool     *connPool
+	network  string
+	addr     string
+	pc       *pooledConn
+	reusable bool
+}
+
+func (b *pooledBody) Close() error {
+	err := b.ReadCloser.Close()
+	if b.reusable {
+		b.pool.put(b.network, b.addr, b.pc)
+	} else {
+		b.pc.conn.Close()
+	}
+	return err
+}
+
+// pooledConn is a connection held by connPool together with the buffered
+// reader wrapping it, so a reused connection keeps reading from wherever
+// its last borrower left off instead of losing bytes already buffered
+// past the previous response's boundary.
+type pooledConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+// connPool is a trivial connection pool keyed on "network addr", reused
+// across requests to the same upstream so each one doesn't pay for a
+// fresh dial.
+type connPool struct {
+	mu    sync.Mutex
+	conns map[string][]*pooledConn
+}
+
+func (p *connPool) get(network, addr string) (*pooledConn, error) {
+	key := network + " " + addr
+
+	p.mu.Lock()
+	if conns := p.conns[key]; len(conns) > 0 {
+		pc := conns[len(conns)-1]
+		p.conns[key] = conns[:len(conns)-1]
+		p.mu.Unlock()
+		return pc, nil
+	}
+	p.mu.Unlock()
+
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	return &pooledConn{conn: conn, br: bufio.NewReader(conn)}, nil
+}
+
+func (p *connPool) put(network, addr string, pc *pooledConn) {
+	key := network + " " + addr
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conns == nil {
+		p.conns = make(map[string][]*pooledConn)
+	}
+	p.conns[key] = append(p.conns[key], pc)
+}
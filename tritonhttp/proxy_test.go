@@ -0,0 +1,145 @@
+package tritonhttp
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"testing"
+)
+
+// serveOnce accepts a single connection on ln, reads one request off it
+// (just enough to get past the header block), and writes resp back
+// verbatim.
+func serveOnce(t *testing.T, ln net.Listener, resp string) {
+	t.Helper()
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Errorf("accept: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	req := &Request{}
+	if _, err := readRequest(bufio.NewReader(conn), req); err != nil {
+		t.Errorf("reading forwarded request: %v", err)
+		return
+	}
+	if _, err := conn.Write([]byte(resp)); err != nil {
+		t.Errorf("writing response: %v", err)
+	}
+}
+
+func TestReverseProxyServe(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	go serveOnce(t, ln, "HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\nhello")
+
+	p := &ReverseProxy{Network: "tcp", Addr: ln.Addr().String()}
+	req := &Request{Method: "GET", URL: "/", Proto: "HTTP/1.1", Host: "example.com", RemoteAddr: "10.0.0.1:1234"}
+	req.Headers.Set("Host", "example.com")
+	res := &Response{}
+
+	if err := p.serve(req, res); err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+	if res.StatusCode != 200 {
+		t.Errorf("StatusCode = %d, want 200", res.StatusCode)
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("reading body: %v", err)
+	}
+	if string(body) != "hello" {
+		t.Errorf("body = %q, want %q", body, "hello")
+	}
+	res.Body.Close()
+}
+
+func TestReverseProxyXForwardedFor(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	var captured Request
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn, err := ln.Accept()
+		if err != nil {
+			t.Errorf("accept: %v", err)
+			return
+		}
+		defer conn.Close()
+		if _, err := readRequest(bufio.NewReader(conn), &captured); err != nil {
+			t.Errorf("reading forwarded request: %v", err)
+			return
+		}
+		conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+	}()
+
+	p := &ReverseProxy{Network: "tcp", Addr: ln.Addr().String()}
+	req := &Request{Method: "GET", URL: "/", Proto: "HTTP/1.1", Host: "example.com", RemoteAddr: "10.0.0.1:1234"}
+	req.Headers.Set("Host", "example.com")
+	res := &Response{}
+	if err := p.serve(req, res); err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+	res.Body.Close()
+	<-done
+
+	if got := captured.Headers.Get("X-Forwarded-For"); got != "10.0.0.1" {
+		t.Errorf("X-Forwarded-For = %q, want %q", got, "10.0.0.1")
+	}
+}
+
+// TestConnPoolReuse verifies a connection is only handed back to a later
+// get() once the reusable body it arrived with has been closed.
+func TestConnPoolReuse(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(io.Discard, conn)
+	}()
+
+	var pool connPool
+	pc1, err := pool.get("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+
+	body := &pooledBody{
+		ReadCloser: io.NopCloser(io.LimitReader(pc1.br, 0)),
+		pool:       &pool,
+		network:    "tcp",
+		addr:       ln.Addr().String(),
+		pc:         pc1,
+		reusable:   true,
+	}
+	if pool.conns != nil && len(pool.conns["tcp "+ln.Addr().String()]) != 0 {
+		t.Fatal("connection pooled before its body was closed")
+	}
+	body.Close()
+
+	pc2, err := pool.get("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("get after reuse: %v", err)
+	}
+	if pc2 != pc1 {
+		t.Error("get after a reusable body Close did not return the pooled connection")
+	}
+}
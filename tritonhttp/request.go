@@ -3,6 +3,7 @@ package tritonhttp
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"strings"
 )
 
@@ -12,10 +13,27 @@ type Request struct {
 	Proto  string // e.g. "HTTP/1.1"
 
 	// Headers stores the key-value HTTP headers
-	Headers map[string]string
+	Headers Headers
 
 	Host  string // determine from the "Host" header
 	Close bool   // determine from the "Connection" header
+
+	// RemoteAddr is the client's address, as reported by the accepted
+	// net.Conn. It is populated by ServeConn and used,
+	// e.g., to build X-Forwarded-For when reverse proxying.
+	RemoteAddr string
+}
+
+// Reset clears req so it can be handed back to a sync.Pool and reused for
+// a later request, retaining Headers' backing array.
+func (req *Request) Reset() {
+	req.Method = ""
+	req.URL = ""
+	req.Proto = ""
+	req.Headers.Reset()
+	req.Host = ""
+	req.Close = false
+	req.RemoteAddr = ""
 }
 
 func readLine(br *bufio.Reader) (string, error) {
@@ -25,44 +43,48 @@ func readLine(br *bufio.Reader) (string, error) {
 	return string(token), err
 }
 
-func readRequest(br *bufio.Reader) (req *Request, fRequest bool, err error) {
-	// Create a Request object
-	req = &Request{}
-
+// readRequest reads one HTTP request off br into req, which the caller is
+// expected to have Reset beforehand (e.g. a pooled, reused Request).
+//
+// Unlike Response.writeStatusLine and writeHeaders, this isn't
+// allocation-free: readLine copies each line off bufio's internal buffer
+// into a fresh string, and parseRequestLine/the header loop below each
+// split it into further allocated slices/strings. See
+// TestReadRequestAllocates in headers_test.go for the measured count.
+func readRequest(br *bufio.Reader, req *Request) (fRequest bool, err error) {
 	// Read the first line of the request received
 	line, err := readLine(br)
 	if err != nil {
-		return nil, true, err
+		return true, err
 	}
 
 	// Parse the first line
 	startLine, err := parseRequestLine(line)
 	if err != nil {
-		return nil, false, err
+		return false, err
 	}
 
 	// Save the Request Method
 	req.Method = startLine[0]
-	if req.Method != "GET" { // Check for the method's validity
-		return nil, false, fmt.Errorf("400: Invalid method")
+	if req.Method != "GET" && req.Method != "HEAD" { // Check for the method's validity
+		return false, fmt.Errorf("400: Invalid method")
 	}
 
 	req.URL = startLine[1] // Save the Request URL
 	if string(req.URL[0]) != "/" {
-		return nil, false, fmt.Errorf("400: Invalid URL")
+		return false, fmt.Errorf("400: Invalid URL")
 	}
 
 	req.Proto = startLine[2]     // Save the Request Version (HTTP/1.1)
 	if req.Proto != "HTTP/1.1" { // Check for the version's validity
-		return nil, false, fmt.Errorf("400: Invalid version")
+		return false, fmt.Errorf("400: Invalid version")
 	}
 
 	// Start reading the headers
-	req.Headers = make(map[string]string)
 	for {
 		line, err := readLine(br)
 		if err != nil {
-			return nil, false, err
+			return false, err
 		}
 
 		// header end
@@ -72,26 +94,26 @@ func readRequest(br *bufio.Reader) (req *Request, fRequest bool, err error) {
 
 		// Check for valid headers
 		if !strings.Contains(line, ":") {
-			return nil, false, fmt.Errorf("400: Invalid header")
+			return false, fmt.Errorf("400: Invalid header")
 		}
 		// extract header information
 		header := strings.Split(line, ":")
 		headerKey := CanonicalHeaderKey(header[0])    // Extract Header Key
 		headerVal := strings.TrimLeft(header[1], " ") // Extract Header Corresponding Value (by removing leading spaces)
-		req.Headers[headerKey] = headerVal            // Save in the Header Map
+		req.Headers.Set(headerKey, headerVal)         // Save in the Headers
 
 	}
 
 	// Check for HOST in Request Headers
-	hostVal, hasHost := req.Headers["Host"]
+	hostVal, hasHost := req.Headers.Peek("Host")
 	if hasHost {
 		req.Host = hostVal
 	} else {
-		return nil, false, fmt.Errorf("400: Host Needed")
+		return false, fmt.Errorf("400: Host Needed")
 	}
 
 	// Check for Connection in Request Header
-	connectionVal, hasConnection := req.Headers["Connection"]
+	connectionVal, hasConnection := req.Headers.Peek("Connection")
 	if hasConnection {
 		if connectionVal == "close" {
 			req.Close = true
@@ -100,7 +122,64 @@ func readRequest(br *bufio.Reader) (req *Request, fRequest bool, err error) {
 		}
 	}
 
-	return req, false, nil
+	return false, nil
+}
+
+// Write serializes req as an HTTP request line, headers, and trailing
+// blank line onto w. It is used to forward a (possibly rewritten) request
+// to an upstream server, e.g. from the reverse proxy.
+func (req *Request) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	if _, err := bw.WriteString(req.Method); err != nil {
+		return err
+	}
+	if err := bw.WriteByte(' '); err != nil {
+		return err
+	}
+	if _, err := bw.WriteString(req.URL); err != nil {
+		return err
+	}
+	if err := bw.WriteByte(' '); err != nil {
+		return err
+	}
+	if _, err := bw.WriteString(req.Proto); err != nil {
+		return err
+	}
+	if _, err := bw.WriteString("\r\n"); err != nil {
+		return err
+	}
+
+	// Headers.Set keeps pairs sorted, so write them directly instead of
+	// going through SortedKeys, which would allocate a fresh key slice.
+	for _, p := range req.Headers.pairs {
+		if _, err := bw.WriteString(p[0]); err != nil {
+			return err
+		}
+		if _, err := bw.WriteString(": "); err != nil {
+			return err
+		}
+		if _, err := bw.WriteString(p[1]); err != nil {
+			return err
+		}
+		if _, err := bw.WriteString("\r\n"); err != nil {
+			return err
+		}
+	}
+	if _, err := bw.WriteString("\r\n"); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// clone returns a shallow copy of req with its own Headers, so callers
+// (e.g. the reverse proxy) can rewrite the outbound request without
+// mutating the original.
+func (req *Request) clone() *Request {
+	out := *req
+	out.Headers = req.Headers.clone()
+	return &out
 }
 
 func parseRequestLine(line string) ([]string, error) {
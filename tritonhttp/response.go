@@ -5,9 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
+	"net"
 	"os"
 	"path/filepath"
-	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -18,7 +20,7 @@ type Response struct {
 	StatusText string // e.g. "OK"
 
 	// Headers stores all headers to write to the response.
-	Headers map[string]string
+	Headers Headers
 
 	// Request is the valid request that leads to this response.
 	// It could be nil for responses not resulting from a valid request.
@@ -28,56 +30,229 @@ type Response struct {
 	// FilePath is the local path to the file to serve.
 	// It could be "", which means there is no file to serve.
 	FilePath string
+
+	// Body, if non-nil, is streamed to the client as the response body
+	// instead of FilePath. It is used for responses whose content isn't
+	// backed by a file on disk, such as FastCGI output.
+	Body io.ReadCloser
+
+	// rangeStart and rangeEnd (inclusive) describe the byte range to
+	// serve out of FilePath for a 206 Partial Content response; they
+	// are only meaningful when hasRange is true.
+	hasRange   bool
+	rangeStart int64
+	rangeEnd   int64
+
+	// conn and rw back Hijack: they're the connection and buffered
+	// reader/writer ServeConn is using to talk to the client, set
+	// before handleGoodRequest hands the request to a handler.
+	conn     net.Conn
+	rw       *bufio.ReadWriter
+	hijacked bool
+
+	// scratch backs the status code digits writeStatusLine formats, so
+	// that formatting doesn't allocate: it's part of the already
+	// heap-allocated Response, reused (and overwritten) on every Write.
+	scratch [8]byte
 }
 
 const (
 	responseProto = "HTTP/1.1"
 
-	statusOK         = 200
-	statusBadRequest = 400
-	statusNotFound   = 404
+	statusOK                  = 200
+	statusPartialContent      = 206
+	statusMovedPermanently    = 301
+	statusFound               = 302
+	statusNotModified         = 304
+	statusBadRequest          = 400
+	statusNotFound            = 404
+	statusRangeNotSatisfiable = 416
+	statusBadGateway          = 502
 )
 
 var statusText = map[int]string{
-	statusOK:         "OK",
-	statusBadRequest: "Bad Request",
-	statusNotFound:   "Not Found",
+	statusOK:                  "OK",
+	statusPartialContent:      "Partial Content",
+	statusMovedPermanently:    "Moved Permanently",
+	statusFound:               "Found",
+	statusNotModified:         "Not Modified",
+	statusBadRequest:          "Bad Request",
+	statusNotFound:            "Not Found",
+	statusRangeNotSatisfiable: "Requested Range Not Satisfiable",
+	statusBadGateway:          "Bad Gateway",
+}
+
+// httpTimeLayout is the time layout FormatTime uses; If-Modified-Since is
+// parsed with the same layout so the two compare correctly.
+const httpTimeLayout = "Mon, 02 Jan 2006 15:04:05 GMT"
+
+// Reset clears res so it can be handed back to a sync.Pool and reused for
+// a later response, retaining Headers' backing array.
+func (res *Response) Reset() {
+	res.Proto = ""
+	res.StatusCode = 0
+	res.StatusText = ""
+	res.Headers.Reset()
+	res.Request = nil
+	res.FilePath = ""
+	res.Body = nil
+	res.hasRange = false
+	res.rangeStart = 0
+	res.rangeEnd = 0
+	res.conn = nil
+	res.rw = nil
+	res.hijacked = false
 }
 
-func (s *Server) handleGoodRequest(req *Request) (res *Response) {
-	res = &Response{}
+// handleGoodRequest fills res to answer req: a reverse proxy or FastCGI
+// route if req.Host/URL matches one, otherwise whatever s.rootHandler()
+// serves (by default, each vhost's docroot).
+func (s *Server) handleGoodRequest(req *Request, res *Response) {
 	if string(req.URL[len(req.URL)-1]) == "/" {
 		req.URL = req.URL + "index.html"
 	}
-	docroot := s.VirtualHosts[req.Host]
-	path := filepath.Join(docroot, req.URL)
 
-	pathRel, err := filepath.Rel(docroot, path)
-	if err != nil || strings.HasPrefix(pathRel, "..") {
-		res.handleNotFound(req)
-		return res
+	// A vhost that is a reverse proxy replaces the docroot entirely.
+	if proxy, ok := s.ReverseProxies[req.Host]; ok {
+		if err := proxy.serve(req, res); err != nil {
+			log.Println("reverse proxy:", err)
+			res.Reset()
+			res.handleBadGateway(req)
+		}
+		return
 	}
-	fi, err := os.Stat(path)
-	if errors.Is(err, os.ErrNotExist) || fi.IsDir() {
-		res.handleNotFound(req)
-		return res
+
+	// FastCGI routes bypass the docroot entirely, so check for one
+	// before touching the filesystem.
+	if route, ok := s.matchFastCGIRoute(req.Host, req.URL); ok {
+		docroot := s.VirtualHosts[req.Host]
+		if err := s.handleFastCGI(req, route, docroot, res); err != nil {
+			res.Reset()
+			if errors.Is(err, errFastCGIInvalidPath) {
+				res.handleNotFound(req)
+				return
+			}
+			log.Println("fastcgi:", err)
+			res.handleBadGateway(req)
+		}
+		return
 	}
 
-	res.FilePath = path
-	res.handleOK(req)
-	return res
+	s.rootHandler().ServeTritonHTTP(res, req)
 }
 
-// HandleOK prepares res to be a 200 OK response
+// HandleOK prepares res to be a 200 OK response, or one of its
+// conditional/partial variants: a 304 Not Modified when If-Modified-Since
+// matches the file, or a 206 Partial Content / 416 Requested Range Not
+// Satisfiable when the request carries a Range header.
 func (res *Response) handleOK(req *Request) {
 	res.init(req)
-	res.StatusCode = statusOK
-	res.StatusText = statusText[res.StatusCode]
 	fi, _ := os.Stat(res.FilePath)
+
+	if ims := req.Headers.Get("If-Modified-Since"); ims != "" {
+		if t, err := time.Parse(httpTimeLayout, ims); err == nil && !fi.ModTime().Truncate(time.Second).After(t) {
+			res.handleNotModified(fi)
+			return
+		}
+	}
+
 	ext := filepath.Ext(res.FilePath)
-	res.Headers["Last-Modified"] = FormatTime(fi.ModTime())
-	res.Headers["Content-Type"] = MIMETypeByExtension(ext)
-	res.Headers["Content-Length"] = fmt.Sprint(fi.Size())
+	res.Headers.Set("Last-Modified", FormatTime(fi.ModTime()))
+	res.Headers.Set("Content-Type", MIMETypeByExtension(ext))
+
+	if rng := req.Headers.Get("Range"); rng != "" {
+		res.handleRange(fi, rng)
+		return
+	}
+
+	res.StatusCode = statusOK
+	res.StatusText = statusText[res.StatusCode]
+	res.Headers.Set("Content-Length", fmt.Sprint(fi.Size()))
+}
+
+// handleNotModified prepares res to be a 304 Not Modified response: it
+// carries validator headers but no body and no Content-Length.
+func (res *Response) handleNotModified(fi os.FileInfo) {
+	res.StatusCode = statusNotModified
+	res.StatusText = statusText[res.StatusCode]
+	res.Headers.Set("Last-Modified", FormatTime(fi.ModTime()))
+	res.FilePath = ""
+}
+
+// handleRange prepares res to serve the single byte range described by
+// the Range header rangeHeader: a 206 Partial Content with the matching
+// slice of the file, or a 416 if the range can't be satisfied.
+func (res *Response) handleRange(fi os.FileInfo, rangeHeader string) {
+	start, end, ok := parseByteRange(rangeHeader, fi.Size())
+	if !ok {
+		res.StatusCode = statusRangeNotSatisfiable
+		res.StatusText = statusText[res.StatusCode]
+		res.Headers.Set("Content-Range", fmt.Sprintf("bytes */%d", fi.Size()))
+		res.FilePath = ""
+		return
+	}
+
+	res.StatusCode = statusPartialContent
+	res.StatusText = statusText[res.StatusCode]
+	res.Headers.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, fi.Size()))
+	res.Headers.Set("Content-Length", fmt.Sprint(end-start+1))
+	res.hasRange = true
+	res.rangeStart = start
+	res.rangeEnd = end
+}
+
+// parseByteRange parses a single-range "bytes=start-end" header (including
+// the "start-" and "-suffixLength" forms) against a file of the given
+// size, returning the inclusive byte range it resolves to.
+func parseByteRange(header string, size int64) (start, end int64, ok bool) {
+	const prefix = "bytes="
+	if !strings.HasPrefix(header, prefix) {
+		return 0, 0, false
+	}
+	spec := strings.TrimPrefix(header, prefix)
+	if strings.Contains(spec, ",") {
+		return 0, 0, false // multiple ranges aren't supported
+	}
+
+	parts := strings.SplitN(spec, "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+
+	switch {
+	case parts[0] == "" && parts[1] != "":
+		n, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false
+		}
+		if n > size {
+			n = size
+		}
+		start, end = size-n, size-1
+	case parts[0] != "" && parts[1] == "":
+		s, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return 0, 0, false
+		}
+		start, end = s, size-1
+	case parts[0] != "" && parts[1] != "":
+		s, err1 := strconv.ParseInt(parts[0], 10, 64)
+		e, err2 := strconv.ParseInt(parts[1], 10, 64)
+		if err1 != nil || err2 != nil {
+			return 0, 0, false
+		}
+		start, end = s, e
+	default:
+		return 0, 0, false
+	}
+
+	if start < 0 || end < start || start >= size {
+		return 0, 0, false
+	}
+	if end >= size {
+		end = size - 1
+	}
+	return start, end, true
 }
 
 // HandleBadRequest prepares res to be a 400 Bad Request response
@@ -85,7 +260,7 @@ func (res *Response) handleBadRequest(req *Request) {
 	res.init(req)
 	res.StatusCode = statusBadRequest
 	res.StatusText = statusText[res.StatusCode]
-	res.Headers["Connection"] = "close"
+	res.Headers.Set("Connection", "close")
 	res.FilePath = ""
 }
 
@@ -97,95 +272,204 @@ func (res *Response) handleNotFound(req *Request) {
 	res.FilePath = ""
 }
 
+// handleBadGateway prepares res to be a 502 Bad Gateway response, used
+// when an upstream (e.g. a FastCGI application server) cannot be reached.
+func (res *Response) handleBadGateway(req *Request) {
+	res.init(req)
+	res.StatusCode = statusBadGateway
+	res.StatusText = statusText[res.StatusCode]
+	res.FilePath = ""
+}
+
 func (res *Response) init(req *Request) {
 	res.Proto = responseProto
 
-	res.Headers = make(map[string]string)
-	res.Headers["Date"] = FormatTime(time.Now())
+	res.Headers.Set("Date", FormatTime(time.Now()))
 	if req != nil && req.Close {
-		res.Headers["Connection"] = "close"
+		res.Headers.Set("Connection", "close")
 	}
 
 	res.Request = req
 }
 
-func (res *Response) Write(w io.Writer) error {
-	err := res.writeStatusLine(w)
+// ReadResponse reads an HTTP response into res, such as the reply from a
+// reverse-proxied upstream server. The caller is expected to have Reset
+// res beforehand. The body is bounded by Content-Length when present,
+// otherwise it reads through to EOF.
+func ReadResponse(br *bufio.Reader, res *Response) error {
+	line, err := readLine(br)
 	if err != nil {
 		return err
 	}
-	err = res.writeHeaders(w)
-	if err != nil {
-		return err
+	fields := strings.SplitN(line, " ", 3)
+	if len(fields) != 3 {
+		return fmt.Errorf("could not parse status line, got %q", line)
 	}
-	err = res.writeBody(w)
+	res.Proto = fields[0]
+	statusCode, err := strconv.Atoi(fields[1])
 	if err != nil {
-		return err
+		return fmt.Errorf("invalid status code %q", fields[1])
+	}
+	res.StatusCode = statusCode
+	res.StatusText = fields[2]
+
+	for {
+		line, err := readLine(br)
+		if err != nil {
+			return err
+		}
+		if line == "" {
+			break
+		}
+		if !strings.Contains(line, ":") {
+			return fmt.Errorf("invalid header line %q", line)
+		}
+		header := strings.SplitN(line, ":", 2)
+		res.Headers.Set(CanonicalHeaderKey(header[0]), strings.TrimSpace(header[1]))
 	}
+
+	var body io.Reader = br
+	if cl := res.Headers.Get("Content-Length"); cl != "" {
+		n, err := strconv.ParseInt(cl, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid Content-Length %q", cl)
+		}
+		body = io.LimitReader(br, n)
+	}
+	res.Body = io.NopCloser(body)
+
 	return nil
 }
 
-func (res *Response) writeStatusLine(w io.Writer) error {
-	bw := bufio.NewWriter(w)
+// Hijack takes the underlying connection away from the server, returning
+// it along with the buffered reader/writer handleClientConnections was
+// using to talk to the client. It lets a handler implement its own
+// Upgrade-based protocol (e.g. a WebSocket handshake): write a "101
+// Switching Protocols" status line and then speak directly on the
+// returned conn. Once hijacked, TritonHTTP's per-connection request loop
+// will not read from or write to the connection again; the caller owns
+// closing it.
+func (res *Response) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if res.conn == nil {
+		return nil, nil, errors.New("tritonhttp: connection not hijackable")
+	}
+	if res.hijacked {
+		return nil, nil, errors.New("tritonhttp: connection already hijacked")
+	}
+	res.hijacked = true
+	return res.conn, res.rw, nil
+}
 
-	statusLine := fmt.Sprintf("%v %v %v\r\n", res.Proto, res.StatusCode, statusText[res.StatusCode])
-	if _, err := bw.WriteString(statusLine); err != nil {
-		return err
+// Write serializes res onto w: the status line, headers, and body, in
+// that order. If w is already a *bufio.Writer (as it is for the
+// connection-lifetime writer ServeConn uses), it's reused directly rather
+// than wrapped in a fresh one.
+func (res *Response) Write(w io.Writer) error {
+	bw, ok := w.(*bufio.Writer)
+	if !ok {
+		bw = bufio.NewWriter(w)
 	}
 
-	if err := bw.Flush(); err != nil {
+	if err := res.writeStatusLine(bw); err != nil {
 		return err
 	}
-	return nil
+	if err := res.writeHeaders(bw); err != nil {
+		return err
+	}
+	if err := res.writeBody(bw); err != nil {
+		return err
+	}
+	return bw.Flush()
 }
 
-func (res *Response) writeHeaders(w io.Writer) error {
-	bw := bufio.NewWriter(w)
-
-	keys := make([]string, 0, len(res.Headers))
-
-	for k := range res.Headers {
-		keys = append(keys, k)
+// writeStatusLine writes the status line without allocating: the status
+// code's digits are formatted into res.scratch, a fixed-size array field
+// that's already part of the heap-allocated Response rather than a fresh
+// allocation per call.
+func (res *Response) writeStatusLine(bw *bufio.Writer) error {
+	if _, err := bw.WriteString(res.Proto); err != nil {
+		return err
+	}
+	if err := bw.WriteByte(' '); err != nil {
+		return err
+	}
+	if _, err := bw.Write(strconv.AppendInt(res.scratch[:0], int64(res.StatusCode), 10)); err != nil {
+		return err
 	}
-	sort.Strings(keys)
+	if err := bw.WriteByte(' '); err != nil {
+		return err
+	}
+	if _, err := bw.WriteString(res.StatusText); err != nil {
+		return err
+	}
+	_, err := bw.WriteString("\r\n")
+	return err
+}
 
-	for _, k := range keys {
-		header := fmt.Sprintf("%v: %v\r\n", k, res.Headers[k])
-		if _, err := bw.WriteString(header); err != nil {
+// writeHeaders writes res.Headers' pairs directly (they're kept sorted by
+// Headers.Set) instead of going through SortedKeys, which would allocate
+// a fresh key slice on every call.
+func (res *Response) writeHeaders(bw *bufio.Writer) error {
+	for _, p := range res.Headers.pairs {
+		if _, err := bw.WriteString(p[0]); err != nil {
+			return err
+		}
+		if _, err := bw.WriteString(": "); err != nil {
+			return err
+		}
+		if _, err := bw.WriteString(p[1]); err != nil {
+			return err
+		}
+		if _, err := bw.WriteString("\r\n"); err != nil {
 			return err
 		}
 	}
-	if _, err := bw.WriteString("\r\n"); err != nil {
-		return err
+	_, err := bw.WriteString("\r\n")
+	return err
+}
+
+func (res *Response) writeBody(bw *bufio.Writer) error {
+	// A HEAD response reports the would-be entity headers but never
+	// sends a body. res.Body still needs closing even though it's never
+	// read here: for a reverse-proxied response it's what returns the
+	// upstream connection to the pool (or closes it).
+	if res.Request != nil && res.Request.Method == "HEAD" {
+		if res.Body != nil {
+			return res.Body.Close()
+		}
+		return nil
 	}
 
-	if err := bw.Flush(); err != nil {
+	if res.Body != nil {
+		defer res.Body.Close()
+		_, err := io.Copy(bw, res.Body)
+		// A body cut off mid-copy leaves unread bytes on the upstream
+		// connection, so it must not go back to the pool.
+		if pb, ok := res.Body.(*pooledBody); ok && err != nil {
+			pb.reusable = false
+		}
 		return err
 	}
-	return nil
-}
 
-func (res *Response) writeBody(w io.Writer) error {
 	if res.FilePath == "" {
 		return nil
 	}
 
-	bw := bufio.NewWriter(w)
-
 	f, err := os.Open(res.FilePath)
 	if err != nil {
 		fmt.Println("File reading error", err)
 		return nil
 	}
+	defer f.Close()
 
-	br := bufio.NewReader(f)
-
-	if _, err := io.Copy(bw, br); err != nil {
+	if res.hasRange {
+		if _, err := f.Seek(res.rangeStart, io.SeekStart); err != nil {
+			return err
+		}
+		_, err := io.CopyN(bw, f, res.rangeEnd-res.rangeStart+1)
 		return err
 	}
 
-	if err := bw.Flush(); err != nil {
-		return err
-	}
-	return nil
+	_, err = io.Copy(bw, f)
+	return err
 }
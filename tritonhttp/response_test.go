@@ -0,0 +1,151 @@
+package tritonhttp
+
+import (
+	"bufio"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeFileInfo is a minimal os.FileInfo stub so range/conditional-GET
+// logic can be tested without touching the filesystem.
+type fakeFileInfo struct {
+	size int64
+}
+
+func (fi fakeFileInfo) Name() string       { return "fake" }
+func (fi fakeFileInfo) Size() int64        { return fi.size }
+func (fi fakeFileInfo) Mode() fs.FileMode  { return 0 }
+func (fi fakeFileInfo) ModTime() time.Time { return time.Time{} }
+func (fi fakeFileInfo) IsDir() bool        { return false }
+func (fi fakeFileInfo) Sys() any           { return nil }
+
+func TestParseByteRange(t *testing.T) {
+	const size = 100
+
+	tests := []struct {
+		name      string
+		header    string
+		wantStart int64
+		wantEnd   int64
+		wantOK    bool
+	}{
+		{"start-end", "bytes=0-49", 0, 49, true},
+		{"start-only", "bytes=50-", 50, 99, true},
+		{"suffix", "bytes=-10", 90, 99, true},
+		{"suffix larger than file", "bytes=-1000", 0, 99, true},
+		{"end clamped to file size", "bytes=90-1000", 90, 99, true},
+		{"missing unit", "0-49", 0, 0, false},
+		{"multiple ranges unsupported", "bytes=0-10,20-30", 0, 0, false},
+		{"start past end of file", "bytes=200-", 0, 0, false},
+		{"end before start", "bytes=50-10", 0, 0, false},
+		{"empty spec", "bytes=", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, ok := parseByteRange(tt.header, size)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if start != tt.wantStart || end != tt.wantEnd {
+				t.Errorf("parseByteRange(%q) = (%d, %d), want (%d, %d)", tt.header, start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestHandleRangeSetsPartialContent(t *testing.T) {
+	res := &Response{}
+	fi := fakeFileInfo{size: 100}
+
+	res.handleRange(fi, "bytes=10-19")
+
+	if res.StatusCode != statusPartialContent {
+		t.Errorf("StatusCode = %d, want %d", res.StatusCode, statusPartialContent)
+	}
+	if !res.hasRange || res.rangeStart != 10 || res.rangeEnd != 19 {
+		t.Errorf("range = (%v, %d, %d), want (true, 10, 19)", res.hasRange, res.rangeStart, res.rangeEnd)
+	}
+	if got := res.Headers.Get("Content-Length"); got != "10" {
+		t.Errorf("Content-Length = %q, want %q", got, "10")
+	}
+}
+
+func TestHandleRangeUnsatisfiable(t *testing.T) {
+	res := &Response{}
+	fi := fakeFileInfo{size: 100}
+
+	res.handleRange(fi, "bytes=200-300")
+
+	if res.StatusCode != statusRangeNotSatisfiable {
+		t.Errorf("StatusCode = %d, want %d", res.StatusCode, statusRangeNotSatisfiable)
+	}
+	if res.FilePath != "" {
+		t.Errorf("FilePath = %q, want empty on a 416", res.FilePath)
+	}
+}
+
+// TestWriteStatusLineUsesStatusTextField is a regression test:
+// writeStatusLine used to re-derive the reason phrase from the
+// hardcoded statusText map instead of using the already-set
+// res.StatusText, so any status code upstream (a FastCGI "Status:"
+// header, a proxied server's status line) that isn't one of the
+// built-ins serialized with an empty reason phrase.
+func TestWriteStatusLineUsesStatusTextField(t *testing.T) {
+	res := &Response{
+		Proto:      responseProto,
+		StatusCode: 201,
+		StatusText: "Created",
+	}
+
+	var buf strings.Builder
+	bw := bufio.NewWriter(&buf)
+	if err := res.writeStatusLine(bw); err != nil {
+		t.Fatalf("writeStatusLine: %v", err)
+	}
+	bw.Flush()
+
+	want := "HTTP/1.1 201 Created\r\n"
+	if buf.String() != want {
+		t.Errorf("writeStatusLine wrote %q, want %q", buf.String(), want)
+	}
+}
+
+// trackedBody wraps a Reader and records whether Close was called, so a
+// test can verify a consumer closed it without reading it (e.g. a HEAD
+// response's body).
+type trackedBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *trackedBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+// TestWriteBodyClosesBodyOnHEAD is a regression test: writeBody's HEAD
+// short-circuit used to return before closing res.Body, which for a
+// reverse-proxied response leaks the upstream connection the body's
+// Close is responsible for returning to the pool.
+func TestWriteBodyClosesBodyOnHEAD(t *testing.T) {
+	body := &trackedBody{Reader: strings.NewReader("should not be read")}
+	res := &Response{
+		Request: &Request{Method: "HEAD"},
+		Body:    body,
+	}
+
+	bw := bufio.NewWriter(io.Discard)
+	if err := res.writeBody(bw); err != nil {
+		t.Fatalf("writeBody: %v", err)
+	}
+	if !body.closed {
+		t.Error("writeBody did not close res.Body on a HEAD response")
+	}
+}
@@ -7,9 +7,18 @@ import (
 	"io"
 	"log"
 	"net"
+	"sync"
 	"time"
 )
 
+// requestPool and responsePool let ServeConn reuse a Request and Response
+// across the many requests a keep-alive connection sends, instead of
+// allocating a fresh pair for every one.
+var (
+	requestPool  = sync.Pool{New: func() any { return new(Request) }}
+	responsePool = sync.Pool{New: func() any { return new(Response) }}
+)
+
 type Server struct {
 	// Addr specifies the TCP address for the server to listen on,
 	// in the form "host:port". It shall be passed to net.Listen()
@@ -20,6 +29,35 @@ type Server struct {
 	// (i.e. the path to the directory to serve static files from) for
 	// all virtual hosts that this server supports
 	VirtualHosts map[string]string
+
+	// FastCGIRoutes maps a host name to the FastCGI rules that route
+	// matching URL prefixes to an upstream application server instead
+	// of serving them from VirtualHosts' docroot.
+	FastCGIRoutes map[string][]FastCGIRoute
+
+	// TLSAddr specifies the TCP address for the server to listen on
+	// for HTTPS connections, in the form "host:port". It shall be
+	// passed to net.Listen() during ListenAndServeTLS().
+	TLSAddr string // e.g. ":443"
+
+	// TLSVirtualHosts contains a mapping from host name to the
+	// certificate chain and key used to terminate TLS for that vhost.
+	// The certificate to present is chosen per-connection via SNI.
+	TLSVirtualHosts map[string]TLSConfig
+
+	// ReverseProxies maps a host name to a reverse proxy that handles
+	// all requests for that vhost by forwarding them to an upstream
+	// server, instead of serving files from VirtualHosts' docroot.
+	ReverseProxies map[string]*ReverseProxy
+
+	// Handler is dispatched to for any request that isn't a reverse
+	// proxy or FastCGI route. If nil, a ServeMux of FileServers built
+	// from VirtualHosts is used instead, preserving the historical
+	// per-vhost docroot behavior.
+	Handler Handler
+
+	defaultHandlerOnce sync.Once
+	defaultHandler     Handler
 }
 
 // ListenAndServe listens on the TCP network address s.Addr and then
@@ -50,25 +88,54 @@ func (s *Server) ListenAndServe() error {
 }
 
 func (s *Server) handleClientConnections(conn net.Conn) {
-	// Read from connection
+	if err := s.ServeConn(conn); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// ServeConn serves requests off an already-accepted connection until the
+// client closes it, asks to via "Connection: close", or a handler
+// hijacks it. It's exported so TritonHTTP can be embedded behind a
+// caller's own listener or accept loop.
+//
+// The connection's Request, Response, and buffered writer are reused
+// across every request the connection sends, rather than reallocated
+// per request.
+func (s *Server) ServeConn(conn net.Conn) error {
+	closeConn := true
+	defer func() {
+		if closeConn {
+			conn.Close()
+		}
+	}()
+
 	br := bufio.NewReader(conn)
+	bw := bufio.NewWriter(conn)
+	rw := bufio.NewReadWriter(br, bw)
+
+	req := requestPool.Get().(*Request)
+	res := responsePool.Get().(*Response)
+	defer func() {
+		req.Reset()
+		res.Reset()
+		requestPool.Put(req)
+		responsePool.Put(res)
+	}()
 
 	// Keep reading for new requests from the same connection
 	for {
 		// Set a read timeout
-		err := conn.SetReadDeadline(time.Now().Add(CONNECT_TIMEOUT))
-		if err != nil {
-			conn.Close()
-			return
+		if err := conn.SetReadDeadline(time.Now().Add(CONNECT_TIMEOUT)); err != nil {
+			return err
 		}
 
 		// Read next request
-		req, noReq, err := readRequest(br)
+		req.Reset()
+		noReq, err := readRequest(br, req)
 		// handle errors
 		// error 1: client has closed the connection
 		if errors.Is(err, io.EOF) {
-			conn.Close()
-			return
+			return nil
 		}
 
 		// error 2: Timeout from the server
@@ -76,38 +143,45 @@ func (s *Server) handleClientConnections(conn net.Conn) {
 		// else, close the connection
 		if err, ok := err.(net.Error); ok && err.Timeout() {
 			if noReq {
-				conn.Close()
-				return
+				return nil
 			}
-			res := &Response{}
+			res.Reset()
 			res.handleBadRequest(req)
-			_ = res.Write(conn)
-			_ = conn.Close()
-			return
+			_ = res.Write(bw)
+			return nil
 		}
 
 		// error 3: malformed/invalid requests
 		if err != nil {
-
-			res := &Response{}
+			res.Reset()
 			res.handleBadRequest(req)
-			_ = res.Write(conn)
-			_ = conn.Close()
-			return
+			_ = res.Write(bw)
+			return nil
 		}
 
 		// Handle Good Requests
 		log.Println("Handling good requests")
-		res := s.handleGoodRequest(req)
-		err = res.Write(conn)
-		if err != nil {
+		req.RemoteAddr = conn.RemoteAddr().String()
+		res.Reset()
+		res.conn = conn
+		res.rw = rw
+		s.handleGoodRequest(req, res)
+
+		// A hijacked connection now belongs to whoever called
+		// res.Hijack(); the request loop must not write to it (the
+		// handler already wrote its own status line, e.g. a 101
+		// Switching Protocols) or touch it again afterwards.
+		if res.hijacked {
+			closeConn = false
+			return nil
+		}
+
+		if err := res.Write(bw); err != nil {
 			fmt.Println(err)
 		}
 
 		if req.Close {
-			conn.Close()
-			return
+			return nil
 		}
-
 	}
 }
@@ -0,0 +1,146 @@
+package tritonhttp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"os"
+)
+
+// TLSConfig points at the PEM-encoded certificate chain and private key
+// files for one virtual host's HTTPS listener.
+type TLSConfig struct {
+	// CertFile is the path to a PEM file containing the leaf
+	// certificate followed by any intermediate certificates.
+	CertFile string
+
+	// KeyFile is the path to the PEM-encoded private key matching the
+	// leaf certificate in CertFile.
+	KeyFile string
+}
+
+// ListenAndServeTLS listens on s.TLSAddr, accepting TLS connections and
+// selecting a certificate per-vhost via SNI before handing the connection
+// off to handleClientConnections.
+func (s *Server) ListenAndServeTLS() error {
+	tlsConfig, err := s.buildTLSConfig()
+	if err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("tcp", s.TLSAddr)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+	defer ln.Close()
+	fmt.Println("Listening for TLS on", ln.Addr())
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+		tlsConn := tls.Server(conn, tlsConfig)
+		fmt.Println("accepted TLS connection", conn.RemoteAddr())
+		go s.handleClientConnections(tlsConn)
+	}
+}
+
+// ListenAndServeBoth runs the plain-HTTP listener on s.Addr and the TLS
+// listener on s.TLSAddr concurrently, returning the first error either one
+// encounters.
+func (s *Server) ListenAndServeBoth() error {
+	errs := make(chan error, 2)
+	go func() { errs <- s.ListenAndServe() }()
+	go func() { errs <- s.ListenAndServeTLS() }()
+	return <-errs
+}
+
+// buildTLSConfig loads every vhost's certificate chain and assembles a
+// tls.Config that selects among them by SNI server name, falling back to
+// one of the configured vhosts when the client doesn't send one or asks
+// for an unknown name. Which vhost that is follows map iteration order,
+// so it is not fixed when more than one is configured.
+func (s *Server) buildTLSConfig() (*tls.Config, error) {
+	certs := make(map[string]*tls.Certificate, len(s.TLSVirtualHosts))
+	var defaultHost string
+
+	for host, cfg := range s.TLSVirtualHosts {
+		chain, err := parsePublicCertFile(cfg.CertFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls: loading cert for %q: %w", host, err)
+		}
+		keyPEM, err := os.ReadFile(cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls: reading key for %q: %w", host, err)
+		}
+		cert, err := tls.X509KeyPair(encodeCertChain(chain), keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("tls: loading keypair for %q: %w", host, err)
+		}
+		certs[host] = &cert
+		if defaultHost == "" {
+			defaultHost = host
+		}
+	}
+
+	return &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if cert, ok := certs[hello.ServerName]; ok {
+				return cert, nil
+			}
+			if cert, ok := certs[defaultHost]; ok {
+				return cert, nil
+			}
+			return nil, fmt.Errorf("tls: no certificate configured for %q", hello.ServerName)
+		},
+	}, nil
+}
+
+// parsePublicCertFile reads every CERTIFICATE block out of a PEM file,
+// returning them in file order as the certificate chain. It errors if the
+// file contains no certificate blocks or a block that isn't a certificate.
+func parsePublicCertFile(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var chain []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("unexpected PEM block type %q in %s", block.Type, path)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing certificate in %s: %w", path, err)
+		}
+		chain = append(chain, cert)
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return chain, nil
+}
+
+// encodeCertChain re-encodes a parsed certificate chain back to PEM so it
+// can be handed to tls.X509KeyPair alongside the key.
+func encodeCertChain(chain []*x509.Certificate) []byte {
+	var buf []byte
+	for _, cert := range chain {
+		buf = append(buf, pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: cert.Raw,
+		})...)
+	}
+	return buf
+}
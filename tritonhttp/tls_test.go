@@ -0,0 +1,104 @@
+package tritonhttp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeSelfSignedCert generates a self-signed ECDSA certificate for host
+// and writes its PEM-encoded cert and key to dir, returning their paths.
+func writeSelfSignedCert(t *testing.T, dir, host string) (certPath, keyPath string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("creating certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshaling key: %v", err)
+	}
+
+	certPath = filepath.Join(dir, host+".crt")
+	keyPath = filepath.Join(dir, host+".key")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("writing cert: %v", err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("writing key: %v", err)
+	}
+	return certPath, keyPath
+}
+
+func TestBuildTLSConfigSelectsCertByServerName(t *testing.T) {
+	dir := t.TempDir()
+	certA, keyA := writeSelfSignedCert(t, dir, "a.example.com")
+	certB, keyB := writeSelfSignedCert(t, dir, "b.example.com")
+
+	s := &Server{
+		TLSVirtualHosts: map[string]TLSConfig{
+			"a.example.com": {CertFile: certA, KeyFile: keyA},
+			"b.example.com": {CertFile: certB, KeyFile: keyB},
+		},
+	}
+
+	cfg, err := s.buildTLSConfig()
+	if err != nil {
+		t.Fatalf("buildTLSConfig: %v", err)
+	}
+
+	cert, err := cfg.GetCertificate(&tls.ClientHelloInfo{ServerName: "b.example.com"})
+	if err != nil {
+		t.Fatalf("GetCertificate(b.example.com): %v", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("parsing returned certificate: %v", err)
+	}
+	if leaf.Subject.CommonName != "b.example.com" {
+		t.Errorf("GetCertificate(b.example.com) returned cert for %q", leaf.Subject.CommonName)
+	}
+
+	// An unrecognized SNI name falls back to some configured vhost
+	// rather than erroring, since TritonHTTP has always needed to
+	// terminate TLS even for requests that don't carry SNI.
+	if _, err := cfg.GetCertificate(&tls.ClientHelloInfo{ServerName: "unknown.example.com"}); err != nil {
+		t.Errorf("GetCertificate(unknown): %v, want a fallback certificate", err)
+	}
+}
+
+func TestParsePublicCertFileRejectsNonCertificateBlock(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bad.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: []byte("not a cert")}), 0o600); err != nil {
+		t.Fatalf("writing file: %v", err)
+	}
+
+	if _, err := parsePublicCertFile(path); err == nil {
+		t.Error("parsePublicCertFile: expected an error for a non-CERTIFICATE PEM block")
+	}
+}